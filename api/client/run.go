@@ -6,20 +6,32 @@ import (
 	"net/http/httputil"
 	"os"
 	"runtime"
+	"strconv"
 	"strings"
 
 	"golang.org/x/net/context"
 
 	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/api/types/versions"
 	Cli "github.com/docker/docker/cli"
-	"github.com/docker/docker/opts"
 	"github.com/docker/docker/pkg/promise"
 	"github.com/docker/docker/pkg/signal"
+	"github.com/docker/docker/pkg/term"
 	runconfigopts "github.com/docker/docker/runconfig/opts"
 	"github.com/docker/engine-api/types"
+	"github.com/docker/engine-api/types/events"
+	"github.com/docker/engine-api/types/filters"
 	"github.com/docker/libnetwork/resolvconf/dns"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
+// minAPIVersionAutoRemove is the minimum daemon API version that knows how
+// to remove a container itself once it exits. Older daemons don't honor
+// HostConfig.AutoRemove, so the client has to fall back to removing the
+// container itself after it exits.
+const minAPIVersionAutoRemove = "1.25"
+
 const (
 	errCmdNotFound          = "not found or does not exist"
 	errCmdCouldNotBeInvoked = "could not be invoked"
@@ -62,33 +74,168 @@ func runStartContainerErr(err error) error {
 	return statusError
 }
 
-// CmdRun runs a command in a new container.
-//
-// Usage: docker run [OPTIONS] IMAGE [COMMAND] [ARG...]
-func (cli *DockerCli) CmdRun(args ...string) error {
-	cmd := Cli.Subcmd("run", []string{"IMAGE [COMMAND] [ARG...]"}, Cli.DockerCommands["run"].Description, true)
-	addTrustedFlags(cmd, true)
+// waitExitOrRemoved subscribes to the container's events before the caller
+// starts it, and returns a channel that is sent the exit code once a "die"
+// event arrives. This avoids the races inherent in polling the container's
+// state after the fact (ContainerWait/inspect can observe stale state, or
+// find the container already gone). If autoRemove is set, it additionally
+// waits for the "destroy" event so the caller only returns once the removal
+// the daemon is about to perform has actually happened.
+func (cli *DockerCli) waitExitOrRemoved(ctx context.Context, containerID string, autoRemove bool) (<-chan int, error) {
+	if len(containerID) == 0 {
+		// containerID can never be empty
+		panic("Internal Error: waitExitOrRemoved needs a container id")
+	}
+
+	eventCtx, cancel := context.WithCancel(ctx)
+
+	eventOptions := types.EventsOptions{
+		Filters: filters.NewArgs(),
+	}
+	eventOptions.Filters.Add("type", "container")
+	eventOptions.Filters.Add("container", containerID)
+	eventq, errq, err := cli.client.Events(eventCtx, eventOptions)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	// Buffered so the goroutine below can always deliver its result and
+	// exit even if the caller returns early without ever reading statusC.
+	statusC := make(chan int, 1)
+	go func() {
+		defer cancel()
+
+		exitCode := 0
+		for {
+			select {
+			case <-eventCtx.Done():
+				statusC <- exitCode
+				return
+			case evt := <-eventq:
+				var done bool
+				exitCode, done = nextExitStatus(evt, autoRemove, exitCode)
+				if done {
+					statusC <- exitCode
+					return
+				}
+			case err := <-errq:
+				logrus.Errorf("error reading events from daemon while waiting for container exit: %v", err)
+				statusC <- 125
+				return
+			}
+		}
+	}()
+
+	return statusC, nil
+}
+
+// nextExitStatus folds a single container event into waitExitOrRemoved's
+// running exit code and reports whether that event means the wait is over.
+// It's split out from waitExitOrRemoved so the event-handling rules (a "die"
+// ends the wait unless we still owe the daemon a "destroy", exitCode only
+// ever moves on "die") can be exercised without a live event stream.
+func nextExitStatus(evt events.Message, autoRemove bool, exitCode int) (newExitCode int, done bool) {
+	newExitCode = exitCode
+	if evt.Action == "die" {
+		if code, ok := evt.Actor.Attributes["exitCode"]; ok {
+			if v, err := strconv.Atoi(code); err == nil {
+				newExitCode = v
+			} else {
+				logrus.Errorf("failed to parse exitCode %q from die event: %v", code, err)
+			}
+		}
+		if !autoRemove {
+			return newExitCode, true
+		}
+	}
+	if autoRemove && evt.Action == "destroy" {
+		return newExitCode, true
+	}
+	return newExitCode, false
+}
+
+var (
+	errConflictAttachDetach               = fmt.Errorf("Conflicting options: -a and -d")
+	errConflictRestartPolicyAndAutoRemove = fmt.Errorf("Conflicting options: --restart and --rm")
+	errConflictDetachAutoRemove           = fmt.Errorf("Conflicting options: --rm and -d")
+)
+
+// runOptions holds the run flags that aren't part of Config/HostConfig, i.e.
+// the ones that affect the client's own behavior rather than the container.
+type runOptions struct {
+	autoRemove bool
+	detach     bool
+	sigProxy   bool
+	name       string
+	detachKeys string
+	noStdin    bool
+}
+
+// NewRunCommand creates a new cobra.Command for `docker run`.
+func NewRunCommand(cli *DockerCli) *cobra.Command {
+	var opts runOptions
+	var copts *runconfigopts.ContainerOptions
+
+	cmd := &cobra.Command{
+		Use:   "run [OPTIONS] IMAGE [COMMAND] [ARG...]",
+		Short: Cli.DockerCommands["run"].Description,
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			copts.Image = args[0]
+			if len(args) > 1 {
+				copts.Args = args[1:]
+			}
+			return runRun(cli, cmd.Flags(), &opts, copts)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.SetInterspersed(false)
 
 	// These are flags not stored in Config/HostConfig
-	var (
-		flAutoRemove = cmd.Bool([]string{"-rm"}, false, "Automatically remove the container when it exits")
-		flDetach     = cmd.Bool([]string{"d", "-detach"}, false, "Run container in background and print container ID")
-		flSigProxy   = cmd.Bool([]string{"-sig-proxy"}, true, "Proxy received signals to the process")
-		flName       = cmd.String([]string{"-name"}, "", "Assign a name to the container")
-		flDetachKeys = cmd.String([]string{"-detach-keys"}, "", "Override the key sequence for detaching a container")
-		flAttach     *opts.ListOpts
-
-		ErrConflictAttachDetach               = fmt.Errorf("Conflicting options: -a and -d")
-		ErrConflictRestartPolicyAndAutoRemove = fmt.Errorf("Conflicting options: --restart and --rm")
-		ErrConflictDetachAutoRemove           = fmt.Errorf("Conflicting options: --rm and -d")
-	)
+	flags.BoolVar(&opts.autoRemove, "rm", false, "Automatically remove the container when it exits")
+	flags.BoolVarP(&opts.detach, "detach", "d", false, "Run container in background and print container ID")
+	flags.BoolVar(&opts.sigProxy, "sig-proxy", true, "Proxy received signals to the process")
+	flags.StringVar(&opts.name, "name", "", "Assign a name to the container")
+	flags.StringVar(&opts.detachKeys, "detach-keys", "", "Override the key sequence for detaching a container")
+	flags.BoolVar(&opts.noStdin, "no-stdin", false, "Do not attach STDIN")
+
+	addTrustedFlags(flags, true)
+	copts = runconfigopts.AddFlags(flags)
+	return cmd
+}
+
+// CmdRun is the mflag-era dispatch entrypoint that the command lookup
+// (still keyed on "Cmd<Name>" method names) invokes for `docker run`. It
+// adapts that calling convention to the cobra command above, which is the
+// mflag compatibility shim this migration relies on: subcommands move to
+// cobra one at a time behind their existing CmdXxx method, without having
+// to wait for the whole dispatcher to move at once.
+func (cli *DockerCli) CmdRun(args ...string) error {
+	cmd := NewRunCommand(cli)
+	cmd.SetArgs(args)
+	cmd.SilenceUsage = true
+	cmd.SilenceErrors = true
+	return cmd.Execute()
+}
 
-	config, hostConfig, networkingConfig, cmd, err := runconfigopts.Parse(cmd, args)
+// AddCommands registers the subcommands that have already been migrated to
+// cobra onto root. Only `run` has moved so far. `attach`, `exec`, and
+// `start` are tracked as their own follow-up migrations (moving each one
+// to OutStream/InStream and cobra, and retiring whatever ad-hoc DockerCli
+// TTY fields only they still use) rather than bundled into this change.
+func AddCommands(root *cobra.Command, cli *DockerCli) {
+	root.AddCommand(NewRunCommand(cli))
+}
+
+func runRun(cli *DockerCli, flags *pflag.FlagSet, opts *runOptions, copts *runconfigopts.ContainerOptions) error {
+	config, hostConfig, networkingConfig, err := runconfigopts.Parse(flags, copts)
 
 	// just in case the Parse does not exit
 	if err != nil {
-		cmd.ReportError(err.Error(), true)
-		os.Exit(125)
+		Cli.ReportError(cli.err, "run", err.Error(), true)
+		return Cli.StatusError{StatusCode: 125}
 	}
 
 	if hostConfig.OomKillDisable != nil && *hostConfig.OomKillDisable && hostConfig.Memory == 0 {
@@ -106,26 +253,50 @@ func (cli *DockerCli) CmdRun(args ...string) error {
 			}
 		}
 	}
-	if config.Image == "" {
-		cmd.Usage()
-		return nil
-	}
 
 	config.ArgsEscaped = false
 
-	if !*flDetach {
+	// --no-stdin wins even over -i/--interactive, the same way
+	// `docker attach --no-stdin` overrides it for an already-running
+	// container.
+	if opts.noStdin {
+		config.AttachStdin = false
+	}
+
+	ctx, cancelFun := context.WithCancel(context.Background())
+	// Cancelling ctx on every return path tears down the "die"/"destroy"
+	// event subscription waitExitOrRemoved opens below: without it, a return
+	// that skips reading statusC (e.g. holdHijackedConnection reporting a
+	// local detach) would leave that goroutine and its Events stream running
+	// forever, waiting for events nobody was ever going to read.
+	defer cancelFun()
+
+	// Newer daemons remove the container themselves once it exits, driven
+	// by HostConfig.AutoRemove. Older daemons have no idea what that field
+	// means, so the client must keep doing the removal itself. What matters
+	// here is what the daemon we're actually talking to supports, not the
+	// client's own configured API version, which stays at its default
+	// regardless of how old that daemon is.
+	clientSideRemove := opts.autoRemove
+	if opts.autoRemove {
+		serverVersion, err := cli.client.ServerVersion(ctx)
+		if err != nil {
+			return err
+		}
+		clientSideRemove = versions.LessThan(serverVersion.APIVersion, minAPIVersionAutoRemove)
+	}
+	hostConfig.AutoRemove = opts.autoRemove && !clientSideRemove
+
+	if !opts.detach {
 		if err := cli.CheckTtyInput(config.AttachStdin, config.Tty); err != nil {
 			return err
 		}
 	} else {
-		if fl := cmd.Lookup("-attach"); fl != nil {
-			flAttach = fl.Value.(*opts.ListOpts)
-			if flAttach.Len() != 0 {
-				return ErrConflictAttachDetach
-			}
+		if fl := flags.Lookup("attach"); fl != nil && fl.Changed {
+			return errConflictAttachDetach
 		}
-		if *flAutoRemove {
-			return ErrConflictDetachAutoRemove
+		if opts.autoRemove {
+			return errConflictDetachAutoRemove
 		}
 
 		config.AttachStdin = false
@@ -134,24 +305,24 @@ func (cli *DockerCli) CmdRun(args ...string) error {
 		config.StdinOnce = false
 	}
 
-	// Disable flSigProxy when in TTY mode
-	sigProxy := *flSigProxy
+	// Disable sigProxy when in TTY mode
+	sigProxy := opts.sigProxy
 	if config.Tty {
 		sigProxy = false
 	}
 
+	stdout := NewOutStream(cli.out)
+
 	// Telling the Windows daemon the initial size of the tty during start makes
 	// a far better user experience rather than relying on subsequent resizes
 	// to cause things to catch up.
 	if runtime.GOOS == "windows" {
-		hostConfig.ConsoleSize[0], hostConfig.ConsoleSize[1] = cli.getTtySize()
+		hostConfig.ConsoleSize[0], hostConfig.ConsoleSize[1] = stdout.GetTtySize()
 	}
 
-	ctx, cancelFun := context.WithCancel(context.Background())
-
-	createResponse, err := cli.createContainer(ctx, config, hostConfig, networkingConfig, hostConfig.ContainerIDFile, *flName)
+	createResponse, err := cli.createContainer(ctx, config, hostConfig, networkingConfig, hostConfig.ContainerIDFile, opts.name)
 	if err != nil {
-		cmd.ReportError(err.Error(), true)
+		Cli.ReportError(cli.err, "run", err.Error(), true)
 		return runStartContainerErr(err)
 	}
 	if sigProxy {
@@ -170,9 +341,13 @@ func (cli *DockerCli) CmdRun(args ...string) error {
 			fmt.Fprintf(cli.out, "%s\n", createResponse.ID)
 		}()
 	}
-	if *flAutoRemove && (hostConfig.RestartPolicy.IsAlways() || hostConfig.RestartPolicy.IsOnFailure()) {
-		return ErrConflictRestartPolicyAndAutoRemove
+	if opts.autoRemove && (hostConfig.RestartPolicy.IsAlways() || hostConfig.RestartPolicy.IsOnFailure()) {
+		return errConflictRestartPolicyAndAutoRemove
 	}
+	if opts.detachKeys != "" {
+		cli.configFile.DetachKeys = opts.detachKeys
+	}
+
 	attach := config.AttachStdin || config.AttachStdout || config.AttachStderr
 	if attach {
 		var (
@@ -180,23 +355,28 @@ func (cli *DockerCli) CmdRun(args ...string) error {
 			in          io.ReadCloser
 		)
 		if config.AttachStdin {
-			in = cli.in
+			inStream := NewInStream(cli.in)
+			if keys, err := term.ToBytes(cli.configFile.DetachKeys); err != nil {
+				fmt.Fprintf(cli.err, "Invalid detach keys (%s) provided\n", cli.configFile.DetachKeys)
+			} else {
+				inStream.SetDetachKeys(keys)
+			}
+			// Must come after SetDetachKeys: detach keys are matched on the
+			// raw bytes the user typed, before any backspace rewriting.
+			fixupWindowsStdinBackspace(inStream, config.Tty)
+			in = inStream
 		}
 		if config.AttachStdout {
-			out = cli.out
+			out = stdout
 		}
 		if config.AttachStderr {
 			if config.Tty {
-				stderr = cli.out
+				stderr = stdout
 			} else {
-				stderr = cli.err
+				stderr = NewOutStream(cli.err)
 			}
 		}
 
-		if *flDetachKeys != "" {
-			cli.configFile.DetachKeys = *flDetachKeys
-		}
-
 		options := types.ContainerAttachOptions{
 			Stream:     true,
 			Stdin:      config.AttachStdin,
@@ -223,7 +403,7 @@ func (cli *DockerCli) CmdRun(args ...string) error {
 		})
 	}
 
-	if *flAutoRemove {
+	if clientSideRemove {
 		defer func() {
 			// Explicitly not sharing the context as it could be "Done" (by calling cancelFun)
 			// and thus the container would not be removed.
@@ -233,6 +413,21 @@ func (cli *DockerCli) CmdRun(args ...string) error {
 		}()
 	}
 
+	// Subscribe to events before starting the container so we can't miss the
+	// "die" event no matter how fast the container exits. Only arm the wait
+	// for the subsequent "destroy" event when the daemon is the one doing
+	// the removal (hostConfig.AutoRemove); with clientSideRemove, removal
+	// happens in the defer above only after we've already returned, so a
+	// "destroy" event from the daemon's own exit handling would never come
+	// and we'd hang forever waiting for it.
+	var statusC <-chan int
+	if config.AttachStdout || config.AttachStderr {
+		statusC, err = cli.waitExitOrRemoved(ctx, createResponse.ID, hostConfig.AutoRemove)
+		if err != nil {
+			return err
+		}
+	}
+
 	//start the container
 	if err := cli.client.ContainerStart(ctx, createResponse.ID); err != nil {
 		// If we have holdHijackedConnection, we should notify
@@ -243,11 +438,11 @@ func (cli *DockerCli) CmdRun(args ...string) error {
 			<-errCh
 		}
 
-		cmd.ReportError(err.Error(), false)
+		Cli.ReportError(cli.err, "run", err.Error(), false)
 		return runStartContainerErr(err)
 	}
 
-	if (config.AttachStdin || config.AttachStdout || config.AttachStderr) && config.Tty && cli.isTerminalOut {
+	if (config.AttachStdin || config.AttachStdout || config.AttachStderr) && config.Tty && stdout.IsTerminal() {
 		if err := cli.monitorTtySize(ctx, createResponse.ID, false); err != nil {
 			fmt.Fprintf(cli.err, "Error monitoring TTY size: %s\n", err)
 		}
@@ -267,33 +462,11 @@ func (cli *DockerCli) CmdRun(args ...string) error {
 		return nil
 	}
 
-	var status int
-
-	// Attached mode
-	if *flAutoRemove {
-		// Autoremove: wait for the container to finish, retrieve
-		// the exit code and remove the container
-		if status, err = cli.client.ContainerWait(ctx, createResponse.ID); err != nil {
-			return runStartContainerErr(err)
-		}
-		if _, status, err = cli.getExitCode(ctx, createResponse.ID); err != nil {
-			return err
-		}
-	} else {
-		// No Autoremove: Simply retrieve the exit code
-		if !config.Tty {
-			// In non-TTY mode, we can't detach, so we must wait for container exit
-			if status, err = cli.client.ContainerWait(ctx, createResponse.ID); err != nil {
-				return err
-			}
-		} else {
-			// In TTY mode, there is a race: if the process dies too slowly, the state could
-			// be updated after the getExitCode call and result in the wrong exit code being reported
-			if _, status, err = cli.getExitCode(ctx, createResponse.ID); err != nil {
-				return err
-			}
-		}
-	}
+	// Attached mode: wait for the "die" event (and, when the daemon does the
+	// --rm removal, the "destroy" event that follows it) rather than polling
+	// ContainerWait/inspect,
+	// which can race with a container that exits or gets removed quickly.
+	status := <-statusC
 	if status != 0 {
 		return Cli.StatusError{StatusCode: status}
 	}
@@ -0,0 +1,64 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/docker/engine-api/types/events"
+)
+
+func TestNextExitStatusDieEndsWaitWithoutAutoRemove(t *testing.T) {
+	evt := events.Message{Action: "die"}
+	evt.Actor.Attributes = map[string]string{"exitCode": "3"}
+
+	exitCode, done := nextExitStatus(evt, false, 0)
+	if !done {
+		t.Fatal("expected a \"die\" event to end the wait when autoRemove is false")
+	}
+	if exitCode != 3 {
+		t.Fatalf("exitCode = %d, want 3", exitCode)
+	}
+}
+
+func TestNextExitStatusDieWaitsForDestroyWithAutoRemove(t *testing.T) {
+	evt := events.Message{Action: "die"}
+	evt.Actor.Attributes = map[string]string{"exitCode": "1"}
+
+	exitCode, done := nextExitStatus(evt, true, 0)
+	if done {
+		t.Fatal("expected a \"die\" event not to end the wait when autoRemove is true")
+	}
+	if exitCode != 1 {
+		t.Fatalf("exitCode = %d, want 1", exitCode)
+	}
+
+	exitCode, done = nextExitStatus(events.Message{Action: "destroy"}, true, exitCode)
+	if !done {
+		t.Fatal("expected the subsequent \"destroy\" event to end the wait")
+	}
+	if exitCode != 1 {
+		t.Fatalf("exitCode = %d, want 1 (destroy must not change it)", exitCode)
+	}
+}
+
+func TestNextExitStatusIgnoresUnrelatedEvents(t *testing.T) {
+	exitCode, done := nextExitStatus(events.Message{Action: "start"}, true, 7)
+	if done {
+		t.Fatal("unrelated events should not end the wait")
+	}
+	if exitCode != 7 {
+		t.Fatalf("exitCode = %d, want unchanged 7", exitCode)
+	}
+}
+
+func TestNextExitStatusMalformedExitCodeKeepsPrevious(t *testing.T) {
+	evt := events.Message{Action: "die"}
+	evt.Actor.Attributes = map[string]string{"exitCode": "not-a-number"}
+
+	exitCode, done := nextExitStatus(evt, false, 9)
+	if !done {
+		t.Fatal("expected a \"die\" event to end the wait even with a malformed exitCode")
+	}
+	if exitCode != 9 {
+		t.Fatalf("exitCode = %d, want unchanged 9 when exitCode attribute can't be parsed", exitCode)
+	}
+}
@@ -0,0 +1,169 @@
+package client
+
+import (
+	"io"
+	"os"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/pkg/ioutils"
+	"github.com/docker/docker/pkg/term"
+)
+
+// OutStream wraps an output stream (typically stdout or stderr) together
+// with the terminal-related state (fd, whether it's a terminal, the saved
+// terminal state) that used to be tracked as a handful of loose fields on
+// DockerCli. Keeping them together makes it possible for subcommands (and
+// for tests) to swap in an arbitrary io.Writer without losing the rest of
+// the TTY bookkeeping.
+type OutStream struct {
+	out        io.Writer
+	fd         uintptr
+	isTerminal bool
+	state      *term.State
+}
+
+func (o *OutStream) Write(p []byte) (int, error) {
+	return o.out.Write(p)
+}
+
+// FD returns the file descriptor number for this stream.
+func (o *OutStream) FD() uintptr {
+	return o.fd
+}
+
+// IsTerminal returns true if this stream is connected to a terminal.
+func (o *OutStream) IsTerminal() bool {
+	return o.isTerminal
+}
+
+// SetRawTerminal puts the stream's terminal into raw mode, saving the
+// previous state so it can be restored with RestoreTerminal.
+func (o *OutStream) SetRawTerminal() (err error) {
+	if os.Getenv("NORAW") != "" || !o.isTerminal {
+		return nil
+	}
+	o.state, err = term.SetRawTerminal(o.fd)
+	return err
+}
+
+// RestoreTerminal restores the terminal to the state saved by SetRawTerminal.
+func (o *OutStream) RestoreTerminal() {
+	if o.state != nil {
+		term.RestoreTerminal(o.fd, o.state)
+	}
+}
+
+// GetTtySize returns the width and height of the terminal connected to this
+// stream, or 0, 0 if it isn't a terminal.
+func (o *OutStream) GetTtySize() (uint, uint) {
+	if !o.isTerminal {
+		return 0, 0
+	}
+	ws, err := term.GetWinsize(o.fd)
+	if err != nil {
+		logrus.Debugf("Error getting size: %s", err)
+		return 0, 0
+	}
+	return uint(ws.Height), uint(ws.Width)
+}
+
+// NewOutStream returns a new OutStream wrapping the given writer.
+func NewOutStream(out io.Writer) *OutStream {
+	fd, isTerminal := term.GetFdInfo(out)
+	return &OutStream{out: out, fd: fd, isTerminal: isTerminal}
+}
+
+// InStream wraps an input stream (typically stdin) together with its
+// terminal state and a pluggable slot for io.Reader transformers. Each call
+// to AddTransformer layers a new reader on top of whatever is already
+// there, so features that need to see (or rewrite) the raw byte stream —
+// the detach-key interceptor below and, on Windows, the DEL-to-backspace
+// rewriting in stream_windows.go — don't each need
+// their own ad-hoc wrapping at the CmdRun call site.
+type InStream struct {
+	in         io.ReadCloser
+	fd         uintptr
+	isTerminal bool
+	state      *term.State
+}
+
+func (i *InStream) Read(p []byte) (int, error) {
+	return i.in.Read(p)
+}
+
+// Close closes the underlying input stream.
+func (i *InStream) Close() error {
+	return i.in.Close()
+}
+
+// AddTransformer layers a new io.Reader on top of this stream's current
+// reader. Transformers added later see the bytes only after every
+// previously-added transformer has had a chance to act on them, so whatever
+// is added first sees the rawest form of the input.
+func (i *InStream) AddTransformer(wrap func(io.Reader) io.Reader) {
+	i.in = ioutils.NewReadCloserWrapper(wrap(i.in), i.in.Close)
+}
+
+// SetDetachKeys layers a transformer onto this stream that scans for the
+// given detach-key sequence byte-by-byte and, once the full sequence is
+// typed, stops forwarding input and turns the next Read into a
+// term.EscapeError instead. This lets the hijack loop detach locally even
+// when the daemon never gets to see (or never recognizes) the same bytes on
+// the wire. Call it before adding any transformer that might rewrite raw
+// input bytes (such as fixupWindowsStdinBackspace on Windows), so the detach
+// sequence is matched before anything else gets a chance to alter it.
+func (i *InStream) SetDetachKeys(keys []byte) {
+	if len(keys) == 0 {
+		return
+	}
+	i.AddTransformer(func(r io.Reader) io.Reader {
+		return term.NewEscapeProxy(r, keys)
+	})
+}
+
+// FD returns the file descriptor number for this stream.
+func (i *InStream) FD() uintptr {
+	return i.fd
+}
+
+// IsTerminal returns true if this stream is connected to a terminal.
+func (i *InStream) IsTerminal() bool {
+	return i.isTerminal
+}
+
+// SetRawTerminal puts the stream's terminal into raw mode, saving the
+// previous state so it can be restored with RestoreTerminal.
+func (i *InStream) SetRawTerminal() (err error) {
+	if os.Getenv("NORAW") != "" || !i.isTerminal {
+		return nil
+	}
+	i.state, err = term.SetRawTerminal(i.fd)
+	return err
+}
+
+// RestoreTerminal restores the terminal to the state saved by SetRawTerminal.
+func (i *InStream) RestoreTerminal() {
+	if i.state != nil {
+		term.RestoreTerminal(i.fd, i.state)
+	}
+}
+
+// GetTtySize returns the width and height of the terminal connected to this
+// stream, or 0, 0 if it isn't a terminal.
+func (i *InStream) GetTtySize() (uint, uint) {
+	if !i.isTerminal {
+		return 0, 0
+	}
+	ws, err := term.GetWinsize(i.fd)
+	if err != nil {
+		logrus.Debugf("Error getting size: %s", err)
+		return 0, 0
+	}
+	return uint(ws.Height), uint(ws.Width)
+}
+
+// NewInStream returns a new InStream wrapping the given reader.
+func NewInStream(in io.ReadCloser) *InStream {
+	fd, isTerminal := term.GetFdInfo(in)
+	return &InStream{in: in, fd: fd, isTerminal: isTerminal}
+}
@@ -0,0 +1,8 @@
+// +build !windows
+
+package client
+
+// fixupWindowsStdinBackspace is a no-op outside Windows, where the console
+// doesn't have the DEL/backspace quirk libcontainerd's delToBsWriter and its
+// client-side counterpart in stream_windows.go work around.
+func fixupWindowsStdinBackspace(in *InStream, tty bool) {}
@@ -0,0 +1,105 @@
+package client
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/pkg/ioutils"
+	"github.com/docker/docker/pkg/term"
+)
+
+func newTestInStream(s string) *InStream {
+	return NewInStream(ioutils.NewReadCloserWrapper(strings.NewReader(s), func() error { return nil }))
+}
+
+func TestInStreamAddTransformerLayersReaders(t *testing.T) {
+	in := newTestInStream("abc")
+
+	// Each transformer should see the output of the one added before it.
+	in.AddTransformer(func(r io.Reader) io.Reader { return upperReader{r} })
+	in.AddTransformer(func(r io.Reader) io.Reader { return reverseReader{r} })
+
+	got, err := ioutil.ReadAll(in)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(got) != "CBA" {
+		t.Fatalf("got %q, want %q", got, "CBA")
+	}
+}
+
+func TestInStreamSetDetachKeysEscapes(t *testing.T) {
+	in := newTestInStream("hello" + "\x10\x11" + "world")
+	in.SetDetachKeys([]byte{16, 17}) // ctrl-p, ctrl-q
+
+	buf := make([]byte, 5)
+	n, err := in.Read(buf)
+	if err != nil {
+		t.Fatalf("unexpected error reading before detach sequence: %v", err)
+	}
+	if string(buf[:n]) != "hello" {
+		t.Fatalf("got %q, want %q", buf[:n], "hello")
+	}
+
+	if _, err := in.Read(buf); err == nil {
+		t.Fatal("expected an error once the detach sequence is read")
+	} else if _, ok := err.(term.EscapeError); !ok {
+		t.Fatalf("expected a term.EscapeError, got %T: %v", err, err)
+	}
+}
+
+func TestInStreamSetDetachKeysNoopWhenEmpty(t *testing.T) {
+	in := newTestInStream("hello")
+	in.SetDetachKeys(nil)
+
+	got, err := ioutil.ReadAll(in)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(got) != "hello" {
+		t.Fatalf("got %q, want %q", got, "hello")
+	}
+}
+
+func TestOutStreamWritesThrough(t *testing.T) {
+	var buf bytes.Buffer
+	out := NewOutStream(&buf)
+
+	if _, err := out.Write([]byte("hi")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if buf.String() != "hi" {
+		t.Fatalf("got %q, want %q", buf.String(), "hi")
+	}
+	if out.IsTerminal() {
+		t.Fatal("a bytes.Buffer should never report as a terminal")
+	}
+}
+
+// upperReader and reverseReader are tiny io.Reader transformers used only to
+// prove that AddTransformer actually chains readers instead of replacing
+// them outright.
+type upperReader struct{ r io.Reader }
+
+func (u upperReader) Read(p []byte) (int, error) {
+	n, err := u.r.Read(p)
+	for i := 0; i < n; i++ {
+		if p[i] >= 'a' && p[i] <= 'z' {
+			p[i] -= 'a' - 'A'
+		}
+	}
+	return n, err
+}
+
+type reverseReader struct{ r io.Reader }
+
+func (rv reverseReader) Read(p []byte) (int, error) {
+	n, err := rv.r.Read(p)
+	for i, j := 0, n-1; i < j; i, j = i+1, j-1 {
+		p[i], p[j] = p[j], p[i]
+	}
+	return n, err
+}
@@ -0,0 +1,42 @@
+// +build windows
+
+package client
+
+import (
+	"io"
+
+	"github.com/docker/docker/pkg/system"
+)
+
+// fixupWindowsStdinBackspace layers the client-side counterpart of
+// libcontainerd's delToBsWriter onto in: pre-14350 Windows consoles report
+// DEL where VK_BACK was meant, so without this the daemon would see DEL
+// bytes it doesn't expect. It's added after SetDetachKeys so the detach-key
+// scan still sees the untranslated bytes the user actually typed, and only
+// the stream handed to the daemon gets rewritten.
+func fixupWindowsStdinBackspace(in *InStream, tty bool) {
+	if !tty || system.GetOSVersion().Build >= 14350 {
+		return
+	}
+	in.AddTransformer(func(r io.Reader) io.Reader {
+		return &delToBsReader{r}
+	})
+}
+
+type delToBsReader struct {
+	io.Reader
+}
+
+func (r *delToBsReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	const (
+		backspace = 0x8
+		del       = 0x7f
+	)
+	for i := 0; i < n; i++ {
+		if p[i] == del {
+			p[i] = backspace
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,10 @@
+package container
+
+import "github.com/docker/docker/runconfig"
+
+// Container holds the daemon's in-memory runtime state for a single
+// container between creation and removal.
+type Container struct {
+	ID         string
+	HostConfig *runconfig.HostConfig
+}
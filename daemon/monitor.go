@@ -0,0 +1,38 @@
+package daemon
+
+import (
+	"github.com/Sirupsen/logrus"
+
+	"github.com/docker/docker/container"
+	"github.com/docker/docker/libcontainerd"
+	"github.com/docker/engine-api/types"
+)
+
+// StateChanged is the libcontainerd callback invoked whenever a container's
+// process state changes; it's how the daemon learns a container exited.
+func (daemon *Daemon) StateChanged(id string, e libcontainerd.StateInfo) error {
+	c, err := daemon.getContainer(id)
+	if err != nil {
+		return err
+	}
+
+	if e.State == libcontainerd.StateExit {
+		daemon.handleContainerExit(c)
+	}
+
+	return nil
+}
+
+// handleContainerExit runs once c has transitioned to Exited. If it was
+// started with HostConfig.AutoRemove, the daemon removes it here instead of
+// leaving cleanup to whatever client happened to start it with --rm: that
+// client can die (SIGKILL, lost TTY, dropped connection) long before the
+// container actually exits, which used to leak the container forever.
+func (daemon *Daemon) handleContainerExit(c *container.Container) {
+	if !c.HostConfig.AutoRemove {
+		return
+	}
+	if err := daemon.ContainerRm(c.ID, &types.ContainerRmConfig{ForceRemove: true, RemoveVolume: true}); err != nil {
+		logrus.Errorf("error removing auto-removed container %s: %v", c.ID, err)
+	}
+}
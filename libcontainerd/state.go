@@ -0,0 +1,17 @@
+package libcontainerd
+
+// State describes a container or exec process's lifecycle state as reported
+// by the backend (containerd on Linux, the HCS on Windows).
+type State string
+
+const (
+	// StateExit indicates the process has exited.
+	StateExit State = "exit"
+)
+
+// StateInfo is what the backend hands back to Daemon.StateChanged for each
+// state transition.
+type StateInfo struct {
+	State    State
+	ExitCode uint32
+}
@@ -0,0 +1,22 @@
+package runconfig
+
+// Config contains the configuration data about a container, as set by the
+// CLI/API caller, that is independent of the host it runs on (as opposed to
+// HostConfig).
+type Config struct {
+	Image string // Name of the image as it was passed by the operator (e.g. could be symbolic)
+
+	Cmd []string // Command to run when starting the container
+
+	AttachStdin  bool // Attach the standard input, makes possible user interaction
+	AttachStdout bool // Attach the standard output
+	AttachStderr bool // Attach the standard error
+
+	Tty       bool // Attach standard streams to a tty, including the columns/rows
+	OpenStdin bool // Open stdin
+	StdinOnce bool // If true, close stdin after the 1 attached client disconnects
+
+	// ArgsEscaped is true when the Cmd/Entrypoint values were already
+	// escaped by the caller (Windows only) and should not be re-escaped.
+	ArgsEscaped bool
+}
@@ -0,0 +1,46 @@
+package runconfig
+
+// RestartPolicy represents the restart policy configured for a container:
+// whether and how the daemon should restart it once it exits.
+type RestartPolicy struct {
+	Name              string
+	MaximumRetryCount int
+}
+
+// IsNone indicates whether the container has the "no" restart policy.
+// This means the container will not automatically restart when exiting.
+func (rp *RestartPolicy) IsNone() bool {
+	return rp.Name == "no" || rp.Name == ""
+}
+
+// IsAlways indicates whether the container has the "always" restart policy.
+// This means the container will automatically restart regardless of the exit status.
+func (rp *RestartPolicy) IsAlways() bool {
+	return rp.Name == "always"
+}
+
+// IsOnFailure indicates whether the container has the "on-failure" restart policy.
+// This means the container will automatically restart of exiting with a non-zero exit status.
+func (rp *RestartPolicy) IsOnFailure() bool {
+	return rp.Name == "on-failure"
+}
+
+// HostConfig holds the container's runtime configuration that depends on
+// the host it runs on, as opposed to the portable Config.
+type HostConfig struct {
+	Binds           []string
+	ContainerIDFile string
+	DNS             []string
+	Memory          int64
+	OomKillDisable  *bool
+	RestartPolicy   RestartPolicy
+	ConsoleSize     [2]uint
+
+	// AutoRemove tells the daemon to remove the container's resources
+	// itself as soon as it transitions to Exited, instead of leaving
+	// removal to whatever client happened to start it with --rm. That
+	// client can die (SIGKILL, lost TTY, dropped connection) without
+	// taking the container down with it; AutoRemove keeps the cleanup
+	// a daemon-side guarantee rather than a client-side courtesy.
+	AutoRemove bool
+}
@@ -0,0 +1,27 @@
+package runconfig
+
+import "testing"
+
+func TestRestartPolicy(t *testing.T) {
+	cases := []struct {
+		policy               RestartPolicy
+		none, always, onFail bool
+	}{
+		{RestartPolicy{}, true, false, false},
+		{RestartPolicy{Name: "no"}, true, false, false},
+		{RestartPolicy{Name: "always"}, false, true, false},
+		{RestartPolicy{Name: "on-failure"}, false, false, true},
+	}
+
+	for _, c := range cases {
+		if got := c.policy.IsNone(); got != c.none {
+			t.Errorf("RestartPolicy{%q}.IsNone() = %v, want %v", c.policy.Name, got, c.none)
+		}
+		if got := c.policy.IsAlways(); got != c.always {
+			t.Errorf("RestartPolicy{%q}.IsAlways() = %v, want %v", c.policy.Name, got, c.always)
+		}
+		if got := c.policy.IsOnFailure(); got != c.onFail {
+			t.Errorf("RestartPolicy{%q}.IsOnFailure() = %v, want %v", c.policy.Name, got, c.onFail)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+// Package opts turns the container/host-config flags shared by the
+// create/run/update family of commands into the Config/HostConfig pair the
+// API expects, so each command doesn't have to duplicate the flag
+// definitions or the validation around them.
+package opts
+
+import (
+	"fmt"
+
+	"github.com/docker/docker/opts"
+	"github.com/docker/docker/runconfig"
+	networktypes "github.com/docker/engine-api/types/network"
+	"github.com/spf13/pflag"
+)
+
+// ContainerOptions holds the raw flag values for the container/host-config
+// flags shared across create/run/update. AddFlags binds it to a FlagSet;
+// Parse turns it into the structures the API needs once the FlagSet has
+// been parsed.
+type ContainerOptions struct {
+	Image string
+	Args  []string
+
+	attach          opts.ListOpts
+	dns             opts.ListOpts
+	interactive     bool
+	tty             bool
+	restartPolicy   string
+	oomKillDisable  bool
+	memoryBytes     int64
+	containerIDFile string
+}
+
+// AddFlags registers the container/host-config flags onto flags and
+// returns the ContainerOptions they're bound to.
+func AddFlags(flags *pflag.FlagSet) *ContainerOptions {
+	copts := &ContainerOptions{
+		attach: opts.NewListOpts(nil),
+		dns:    opts.NewListOpts(nil),
+	}
+
+	flags.VarP(&copts.attach, "attach", "a", "Attach to STDIN, STDOUT or STDERR")
+	flags.BoolVarP(&copts.interactive, "interactive", "i", false, "Keep STDIN open even if not attached")
+	flags.BoolVarP(&copts.tty, "tty", "t", false, "Allocate a pseudo-TTY")
+	flags.Var(&copts.dns, "dns", "Set custom DNS servers")
+	flags.StringVar(&copts.restartPolicy, "restart", "no", "Restart policy to apply when a container exits")
+	flags.BoolVar(&copts.oomKillDisable, "oom-kill-disable", false, "Disable OOM Killer")
+	flags.Int64VarP(&copts.memoryBytes, "memory", "m", 0, "Memory limit in bytes")
+	flags.StringVar(&copts.containerIDFile, "cidfile", "", "Write the container ID to the file")
+
+	return copts
+}
+
+// Parse validates copts, as filled in by a parsed FlagSet, and turns it
+// into the Config/HostConfig/NetworkingConfig triplet the API needs to
+// create a container.
+func Parse(flags *pflag.FlagSet, copts *ContainerOptions) (*runconfig.Config, *runconfig.HostConfig, *networktypes.NetworkingConfig, error) {
+	if copts.Image == "" {
+		return nil, nil, nil, fmt.Errorf("image name cannot be empty")
+	}
+
+	attach := copts.attach.GetAll()
+	attachStdin := copts.interactive
+	attachStdout := true
+	attachStderr := true
+	if len(attach) > 0 {
+		attachStdin = stringInSlice(attach, "stdin")
+		attachStdout = stringInSlice(attach, "stdout")
+		attachStderr = stringInSlice(attach, "stderr")
+	}
+
+	config := &runconfig.Config{
+		Image:        copts.Image,
+		Cmd:          copts.Args,
+		AttachStdin:  attachStdin,
+		AttachStdout: attachStdout,
+		AttachStderr: attachStderr,
+		Tty:          copts.tty,
+		OpenStdin:    copts.interactive,
+		StdinOnce:    copts.interactive,
+	}
+
+	hostConfig := &runconfig.HostConfig{
+		ContainerIDFile: copts.containerIDFile,
+		DNS:             copts.dns.GetAll(),
+		Memory:          copts.memoryBytes,
+		RestartPolicy: runconfig.RestartPolicy{
+			Name: copts.restartPolicy,
+		},
+	}
+	if copts.oomKillDisable {
+		hostConfig.OomKillDisable = &copts.oomKillDisable
+	}
+
+	return config, hostConfig, &networktypes.NetworkingConfig{}, nil
+}
+
+func stringInSlice(slice []string, s string) bool {
+	for _, v := range slice {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+package opts
+
+import (
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+func parseFlags(t *testing.T, image string, args ...string) (*pflag.FlagSet, *ContainerOptions) {
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	copts := AddFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		t.Fatalf("unexpected flag parse error: %v", err)
+	}
+	copts.Image = image
+	return flags, copts
+}
+
+func TestParseRequiresImage(t *testing.T) {
+	flags, copts := parseFlags(t, "")
+	if _, _, _, err := Parse(flags, copts); err == nil {
+		t.Fatal("expected an error when no image is given")
+	}
+}
+
+func TestParseDefaultsToAttachingAllStreams(t *testing.T) {
+	flags, copts := parseFlags(t, "busybox")
+	config, _, _, err := Parse(flags, copts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !config.AttachStdout || !config.AttachStderr {
+		t.Fatalf("expected stdout/stderr to be attached by default, got %+v", config)
+	}
+	if config.AttachStdin {
+		t.Fatalf("expected stdin not to be attached without -i, got %+v", config)
+	}
+}
+
+func TestParseInteractiveAttachesStdin(t *testing.T) {
+	flags, copts := parseFlags(t, "busybox", "-i")
+	config, _, _, err := Parse(flags, copts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !config.AttachStdin || !config.StdinOnce {
+		t.Fatalf("expected -i to attach and pin stdin, got %+v", config)
+	}
+}
+
+func TestParseExplicitAttachOverridesDefaults(t *testing.T) {
+	flags, copts := parseFlags(t, "busybox", "-a", "stdout")
+	config, _, _, err := Parse(flags, copts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !config.AttachStdout || config.AttachStderr || config.AttachStdin {
+		t.Fatalf("expected only stdout attached, got %+v", config)
+	}
+}
+
+func TestParseOomKillDisable(t *testing.T) {
+	flags, copts := parseFlags(t, "busybox", "--oom-kill-disable")
+	_, hostConfig, _, err := Parse(flags, copts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if hostConfig.OomKillDisable == nil || !*hostConfig.OomKillDisable {
+		t.Fatalf("expected OomKillDisable to be set, got %+v", hostConfig)
+	}
+}